@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+)
+
+const (
+	FRAMING_DELIMITER      = "delimiter"
+	FRAMING_LENGTH_PREFIX  = "length-prefixed"
+	FRAMING_NETSTRING      = "netstring"
+	FRAMING_HTTP_RECORD    = "http-record"
+	defaultCopyBufferBytes = 32 * 1024
+)
+
+var validFramings = map[string]bool{
+	FRAMING_DELIMITER:     true,
+	FRAMING_LENGTH_PREFIX: true,
+	FRAMING_NETSTRING:     true,
+	FRAMING_HTTP_RECORD:   true,
+}
+
+// boundedBuffer accumulates up to limit bytes and reports whether the
+// stream was truncated, while still accepting (and discarding) writes
+// beyond that so the underlying copy can drain the full stream. totalBytes
+// tracks every byte that passed through Write, including dropped ones, so
+// callers can report the true size of the stream even when truncated.
+type boundedBuffer struct {
+	limit      int64
+	buf        bytes.Buffer
+	truncated  bool
+	totalBytes int64
+}
+
+func (b *boundedBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	b.totalBytes += int64(n)
+
+	if b.limit <= 0 {
+		b.buf.Write(p)
+		return n, nil
+	}
+
+	remaining := b.limit - int64(b.buf.Len())
+	if remaining <= 0 {
+		b.truncated = true
+		return n, nil
+	}
+	if int64(n) > remaining {
+		b.buf.Write(p[:remaining])
+		b.truncated = true
+		return n, nil
+	}
+
+	b.buf.Write(p)
+	return n, nil
+}
+
+// capturedBody is a request body that has been streamed into a bounded
+// buffer, along with metadata needed to verify capture integrity.
+type capturedBody struct {
+	Bytes         []byte
+	ContentLength int64
+	SHA256        string
+	Truncated     bool
+}
+
+// finalizeCapturedBody derives a capturedBody from a boundedBuffer that a
+// caller has already drained by some means (a direct copy, or as the tee
+// destination of a stream relayed elsewhere).
+func finalizeCapturedBody(bb *boundedBuffer) capturedBody {
+	sum := sha256.Sum256(bb.buf.Bytes())
+	return capturedBody{
+		Bytes:         bb.buf.Bytes(),
+		ContentLength: bb.totalBytes,
+		SHA256:        hex.EncodeToString(sum[:]),
+		Truncated:     bb.truncated,
+	}
+}
+
+// readCapturedBody streams r into a buffer capped at maxBodySize bytes
+// (0 means unlimited), using a fixed-size copy buffer so an oversized
+// body cannot exhaust memory. Bytes beyond the cap are dropped, not
+// buffered.
+func readCapturedBody(r io.Reader, maxBodySize int64) (capturedBody, error) {
+	bb := &boundedBuffer{limit: maxBodySize}
+	copyBuf := make([]byte, defaultCopyBufferBytes)
+
+	if _, err := io.CopyBuffer(bb, r, copyBuf); err != nil {
+		return capturedBody{}, err
+	}
+
+	return finalizeCapturedBody(bb), nil
+}
+
+// writeFramedBody writes body to dest framed according to framing, so a
+// stream of multiple captured requests can be unambiguously split back
+// into individual records by a downstream consumer. The record is
+// assembled in memory and written in a single Write call, so it lands
+// atomically even when dest is shared by concurrent requests.
+func writeFramedBody(dest io.Writer, framing string, delimiter string, body []byte) error {
+	var record bytes.Buffer
+
+	switch framing {
+	case FRAMING_LENGTH_PREFIX:
+		var prefix [8]byte
+		binary.BigEndian.PutUint64(prefix[:], uint64(len(body)))
+		record.Write(prefix[:])
+		record.Write(body)
+	case FRAMING_NETSTRING:
+		fmt.Fprintf(&record, "%d:", len(body))
+		record.Write(body)
+		record.WriteString(",")
+	case FRAMING_HTTP_RECORD:
+		fmt.Fprintf(&record, "%x\r\n", len(body))
+		record.Write(body)
+		record.WriteString("\r\n")
+	case FRAMING_DELIMITER:
+		fallthrough
+	default:
+		record.Write(body)
+		record.WriteString(delimiter)
+	}
+
+	_, err := dest.Write(record.Bytes())
+	return err
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestWriteFramedBodyDelimiter(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeFramedBody(&buf, FRAMING_DELIMITER, "\n", []byte("hello")); err != nil {
+		t.Fatalf("writeFramedBody returned error: %v", err)
+	}
+	if buf.String() != "hello\n" {
+		t.Fatalf("got %q, want %q", buf.String(), "hello\n")
+	}
+}
+
+func TestWriteFramedBodyLengthPrefixed(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeFramedBody(&buf, FRAMING_LENGTH_PREFIX, "", []byte("hello")); err != nil {
+		t.Fatalf("writeFramedBody returned error: %v", err)
+	}
+
+	got := buf.Bytes()
+	if len(got) != 8+len("hello") {
+		t.Fatalf("got %d bytes, want %d", len(got), 8+len("hello"))
+	}
+	if n := binary.BigEndian.Uint64(got[:8]); n != 5 {
+		t.Fatalf("length prefix = %d, want 5", n)
+	}
+	if string(got[8:]) != "hello" {
+		t.Fatalf("body = %q, want %q", got[8:], "hello")
+	}
+}
+
+func TestWriteFramedBodyNetstring(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeFramedBody(&buf, FRAMING_NETSTRING, "", []byte("hello")); err != nil {
+		t.Fatalf("writeFramedBody returned error: %v", err)
+	}
+	if buf.String() != "5:hello," {
+		t.Fatalf("got %q, want %q", buf.String(), "5:hello,")
+	}
+}
+
+func TestWriteFramedBodyHTTPRecord(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeFramedBody(&buf, FRAMING_HTTP_RECORD, "", []byte("hello")); err != nil {
+		t.Fatalf("writeFramedBody returned error: %v", err)
+	}
+	if buf.String() != "5\r\nhello\r\n" {
+		t.Fatalf("got %q, want %q", buf.String(), "5\r\nhello\r\n")
+	}
+}
+
+func TestBoundedBufferWithinLimit(t *testing.T) {
+	bb := &boundedBuffer{limit: 10}
+	n, err := bb.Write([]byte("hello"))
+	if err != nil || n != 5 {
+		t.Fatalf("Write = (%d, %v), want (5, nil)", n, err)
+	}
+	if bb.truncated {
+		t.Fatal("expected truncated = false")
+	}
+	if bb.totalBytes != 5 {
+		t.Fatalf("totalBytes = %d, want 5", bb.totalBytes)
+	}
+}
+
+func TestBoundedBufferTruncates(t *testing.T) {
+	bb := &boundedBuffer{limit: 3}
+	n, err := bb.Write([]byte("hello"))
+	if err != nil || n != 5 {
+		t.Fatalf("Write = (%d, %v), want (5, nil)", n, err)
+	}
+	if !bb.truncated {
+		t.Fatal("expected truncated = true")
+	}
+	if bb.buf.String() != "hel" {
+		t.Fatalf("buffered = %q, want %q", bb.buf.String(), "hel")
+	}
+	if bb.totalBytes != 5 {
+		t.Fatalf("totalBytes = %d, want 5 (true size despite truncation)", bb.totalBytes)
+	}
+}
+
+func TestBoundedBufferUnlimited(t *testing.T) {
+	bb := &boundedBuffer{limit: 0}
+	bb.Write([]byte("hello world"))
+	if bb.truncated {
+		t.Fatal("expected truncated = false when limit is 0 (unlimited)")
+	}
+	if bb.buf.String() != "hello world" {
+		t.Fatalf("buffered = %q, want full body", bb.buf.String())
+	}
+}
+
+func TestReadCapturedBodyTruncation(t *testing.T) {
+	r := strings.NewReader("hello world")
+	cb, err := readCapturedBody(r, 5)
+	if err != nil {
+		t.Fatalf("readCapturedBody returned error: %v", err)
+	}
+	if !cb.Truncated {
+		t.Fatal("expected Truncated = true")
+	}
+	if string(cb.Bytes) != "hello" {
+		t.Fatalf("Bytes = %q, want %q", cb.Bytes, "hello")
+	}
+	if cb.ContentLength != int64(len("hello world")) {
+		t.Fatalf("ContentLength = %d, want true incoming size %d", cb.ContentLength, len("hello world"))
+	}
+
+	sum := sha256.Sum256([]byte("hello"))
+	if cb.SHA256 != hex.EncodeToString(sum[:]) {
+		t.Fatalf("SHA256 = %q, want hash of truncated bytes", cb.SHA256)
+	}
+}
+
+func TestReadCapturedBodyUnlimited(t *testing.T) {
+	r := strings.NewReader("hello world")
+	cb, err := readCapturedBody(r, 0)
+	if err != nil {
+		t.Fatalf("readCapturedBody returned error: %v", err)
+	}
+	if cb.Truncated {
+		t.Fatal("expected Truncated = false")
+	}
+	if string(cb.Bytes) != "hello world" {
+		t.Fatalf("Bytes = %q, want full body", cb.Bytes)
+	}
+}
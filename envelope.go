@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+const (
+	FORMAT_RAW    = "raw"
+	FORMAT_JSON   = "json"
+	FORMAT_NDJSON = "ndjson"
+	FORMAT_CBOR   = "cbor"
+)
+
+var validFormats = map[string]bool{
+	FORMAT_RAW:    true,
+	FORMAT_JSON:   true,
+	FORMAT_NDJSON: true,
+	FORMAT_CBOR:   true,
+}
+
+// RequestEnvelope is the structured record emitted for a single captured
+// request when -format is anything other than "raw".
+type RequestEnvelope struct {
+	Method        string              `json:"method" cbor:"method"`
+	Path          string              `json:"path" cbor:"path"`
+	RemoteAddr    string              `json:"remote_addr" cbor:"remote_addr"`
+	Headers       map[string][]string `json:"headers" cbor:"headers"`
+	Timestamp     time.Time           `json:"timestamp" cbor:"timestamp"`
+	BodyBase64    string              `json:"body_base64" cbor:"body_base64"`
+	ClientCert    *ClientCertInfo     `json:"client_cert,omitempty" cbor:"client_cert,omitempty"`
+	ContentLength int64               `json:"content_length" cbor:"content_length"`
+	SHA256        string              `json:"sha256" cbor:"sha256"`
+	Truncated     bool                `json:"truncated,omitempty" cbor:"truncated,omitempty"`
+}
+
+func newRequestEnvelope(r *http.Request, body capturedBody) RequestEnvelope {
+	return RequestEnvelope{
+		Method:        r.Method,
+		Path:          r.URL.String(),
+		RemoteAddr:    r.RemoteAddr,
+		Headers:       map[string][]string(r.Header),
+		Timestamp:     time.Now(),
+		BodyBase64:    base64.StdEncoding.EncodeToString(body.Bytes),
+		ClientCert:    clientCertInfo(r),
+		ContentLength: body.ContentLength,
+		SHA256:        body.SHA256,
+		Truncated:     body.Truncated,
+	}
+}
+
+// encodeEnvelope renders env (a RequestEnvelope or ResponseEnvelope)
+// according to format. For ndjson and json the result is
+// newline-terminated so records can be appended to a stream; cbor records
+// are self-delimiting and are written back-to-back with no separator.
+func encodeEnvelope(format string, env any) ([]byte, error) {
+	switch format {
+	case FORMAT_NDJSON:
+		data, err := json.Marshal(env)
+		if err != nil {
+			return nil, err
+		}
+		return append(data, '\n'), nil
+	case FORMAT_JSON:
+		data, err := json.MarshalIndent(env, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append(data, '\n'), nil
+	case FORMAT_CBOR:
+		return cbor.Marshal(env)
+	default:
+		return nil, fmt.Errorf("unsupported envelope format: %v", format)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+func TestEncodeEnvelopeNDJSON(t *testing.T) {
+	env := RequestEnvelope{Method: "GET", Path: "/foo"}
+
+	data, err := encodeEnvelope(FORMAT_NDJSON, env)
+	if err != nil {
+		t.Fatalf("encodeEnvelope returned error: %v", err)
+	}
+	if !strings.HasSuffix(string(data), "\n") {
+		t.Fatalf("ndjson record not newline-terminated: %q", data)
+	}
+
+	var got RequestEnvelope
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("could not decode ndjson record: %v", err)
+	}
+	if got.Method != "GET" || got.Path != "/foo" {
+		t.Fatalf("decoded record mismatch: %+v", got)
+	}
+}
+
+func TestEncodeEnvelopeJSONPretty(t *testing.T) {
+	env := RequestEnvelope{Method: "POST"}
+
+	data, err := encodeEnvelope(FORMAT_JSON, env)
+	if err != nil {
+		t.Fatalf("encodeEnvelope returned error: %v", err)
+	}
+	if !strings.Contains(string(data), "\n  ") {
+		t.Fatalf("json format should be indented, got: %q", data)
+	}
+}
+
+func TestEncodeEnvelopeCBORRoundTrip(t *testing.T) {
+	env := RequestEnvelope{Method: "PUT", Path: "/bar", SHA256: "abc"}
+
+	data, err := encodeEnvelope(FORMAT_CBOR, env)
+	if err != nil {
+		t.Fatalf("encodeEnvelope returned error: %v", err)
+	}
+
+	var got RequestEnvelope
+	if err := cbor.Unmarshal(data, &got); err != nil {
+		t.Fatalf("could not decode cbor record: %v", err)
+	}
+	if got.Method != "PUT" || got.Path != "/bar" || got.SHA256 != "abc" {
+		t.Fatalf("decoded record mismatch: %+v", got)
+	}
+}
+
+func TestEncodeEnvelopeUnsupportedFormat(t *testing.T) {
+	if _, err := encodeEnvelope("xml", RequestEnvelope{}); err == nil {
+		t.Fatal("expected an error for an unsupported format")
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+)
+
+// formatHeaderDump renders r's request line and headers the way the
+// -headers destination expects: one raw HTTP-style block per request,
+// terminated by a blank line. When r was authenticated via mTLS, the
+// client certificate's subject and SANs are appended as synthetic
+// X-Client-Cert-* header lines.
+func formatHeaderDump(r *http.Request, cert *ClientCertInfo) []byte {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "%s %s\r\n", r.Method, r.URL.String())
+	for name, values := range r.Header {
+		for _, value := range values {
+			fmt.Fprintf(&buf, "%s: %s\r\n", name, value)
+		}
+	}
+	if cert != nil {
+		fmt.Fprintf(&buf, "X-Client-Cert-Subject: %s\r\n", cert.Subject)
+		for _, san := range cert.SANs {
+			fmt.Fprintf(&buf, "X-Client-Cert-SAN: %s\r\n", san)
+		}
+	}
+	buf.WriteString("\r\n")
+
+	return buf.Bytes()
+}
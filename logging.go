@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// resolveLogWriter turns a single -log destination token into an io.Writer.
+// STDOUT/STDERR are discarded if the body or headers destination already
+// writes to that same stream, to avoid interleaving logs with captured data.
+// SYSLOG reuses the shared syslog writer built from -syslog. Any other
+// value is treated as a path to a rotating log file.
+func resolveLogWriter(dest string, opts options, syslogW *syslogWriter) io.Writer {
+	switch dest {
+	case DESTINATION_STDOUT:
+		if opts.body == DESTINATION_STDOUT || opts.headers == DESTINATION_STDOUT {
+			return io.Discard
+		}
+		return os.Stdout
+	case DESTINATION_STDERR:
+		if opts.body == DESTINATION_STDERR || opts.headers == DESTINATION_STDERR {
+			return io.Discard
+		}
+		return os.Stderr
+	case DESTINATION_SYSLOG:
+		if syslogW == nil {
+			return io.Discard
+		}
+		// Wrapped so a syslog transport error (e.g. mid-backoff) can't
+		// make io.MultiWriter abort writes to the other log destinations.
+		return bestEffortWriter{syslogW}
+	default:
+		return &lumberjack.Logger{
+			Filename:   dest,
+			MaxSize:    opts.logMaxSize,
+			MaxAge:     opts.logMaxAge,
+			MaxBackups: opts.logMaxBackups,
+			Compress:   opts.logCompress,
+		}
+	}
+}
+
+// sinkRegistry lazily opens and caches io.Writers for body destinations, so
+// rules can route requests to named sinks (plain file paths, STDOUT,
+// STDERR or SYSLOG) without reopening a file per request.
+type sinkRegistry struct {
+	mu      sync.Mutex
+	syslogW *syslogWriter
+	writers map[string]io.Writer
+}
+
+func newSinkRegistry(syslogW *syslogWriter) *sinkRegistry {
+	return &sinkRegistry{
+		syslogW: syslogW,
+		writers: make(map[string]io.Writer),
+	}
+}
+
+func (s *sinkRegistry) get(dest string) (io.Writer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if w, ok := s.writers[dest]; ok {
+		return w, nil
+	}
+
+	var w io.Writer
+	switch dest {
+	case DESTINATION_STDOUT:
+		w = os.Stdout
+	case DESTINATION_STDERR:
+		w = os.Stderr
+	case DESTINATION_SYSLOG:
+		if s.syslogW == nil {
+			return nil, fmt.Errorf("sink %v is SYSLOG but -syslog was not set", dest)
+		}
+		w = s.syslogW
+	default:
+		f, err := os.Create(dest)
+		if err != nil {
+			return nil, fmt.Errorf("could not open sink file at %v: %w", dest, err)
+		}
+		w = f
+	}
+
+	// Every request is handled on its own goroutine, so a sink shared
+	// across requests (the default body/headers destination, or a named
+	// rule sink) needs its writes serialized: otherwise concurrent
+	// requests can interleave partial records.
+	w = &syncWriter{w: w}
+
+	s.writers[dest] = w
+	return w, nil
+}
+
+// syncWriter serializes Write calls to an underlying io.Writer with a
+// mutex, so concurrent callers can't interleave partial writes into a
+// single shared sink.
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (s *syncWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Write(p)
+}
+
+func initLogging(opts options, syslogW *syslogWriter) (*slog.Logger, error) {
+	destinations := strings.Split(opts.log, ",")
+	writers := make([]io.Writer, 0, len(destinations))
+	for _, dest := range destinations {
+		dest = strings.TrimSpace(dest)
+		if dest == "" {
+			continue
+		}
+		writers = append(writers, resolveLogWriter(dest, opts, syslogW))
+	}
+
+	var writer io.Writer
+	if len(writers) == 0 {
+		writer = io.Discard
+	} else {
+		writer = io.MultiWriter(writers...)
+	}
+
+	var logLevel = new(slog.LevelVar)
+	logLevel.Set(slog.Level(opts.logLevel))
+	logger := slog.New(slog.NewTextHandler(writer, &slog.HandlerOptions{Level: logLevel}))
+	return logger, nil
+}
@@ -10,6 +10,7 @@ import (
 	"math"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
 	"time"
@@ -18,16 +19,35 @@ import (
 const (
 	DESTINATION_STDOUT = "STDOUT"
 	DESTINATION_STDERR = "STDERR"
+	DESTINATION_SYSLOG = "SYSLOG"
 )
 
 type options struct {
-	port          uint16
-	netInterface  net.IP
-	body          string
-	bodyDelimiter string
-	headers       string
-	log           string
-	logLevel      int
+	port            uint16
+	netInterface    net.IP
+	body            string
+	bodyDelimiter   string
+	headers         string
+	log             string
+	logLevel        int
+	format          string
+	tlsEnabled      bool
+	tlsCert         string
+	tlsKey          string
+	tlsClientCA     string
+	logMaxSize      int
+	logMaxAge       int
+	logMaxBackups   int
+	logCompress     bool
+	syslogURL       string
+	syslogFacility  int
+	syslogSeverity  int
+	syslogAppName   string
+	maxBodySize     int64
+	bodyFraming     string
+	upstream        string
+	captureResponse bool
+	rulesPath       string
 }
 
 var logLevelMap = map[string]slog.Level{
@@ -58,13 +78,52 @@ func initFlags() (options, error) {
 	headersFlag := flag.String("headers", "", "where to write the request headers. Valid options are STDOUT, STDERR or a path to a file.")
 	flag.StringVar(headersFlag, "H", *headersFlag, "alias for -headers")
 
-	logFlag := flag.String("log", DESTINATION_STDERR, "where to write logs. Logs will be discarded if you set any output flag to STDERR. Valid options are STDOUT, STDERR or a path to a file.")
+	logFlag := flag.String("log", DESTINATION_STDERR, "where to write logs. Logs will be discarded if you set any output flag to STDERR. Valid options are STDOUT, STDERR or a path to a file, and a comma-separated combination thereof (e.g. STDERR,/var/log/httpcat.log).")
 	flag.StringVar(logFlag, "l", *logFlag, "alias for -log")
 
 	logLevelFlag := flag.String("verbosity", "", "logging verbosity. Valid options are error, warn, info, debug.")
 
+	logMaxSizeFlag := flag.Int("log-max-size", 100, "maximum size in megabytes of a log file before it gets rotated.")
+	logMaxAgeFlag := flag.Int("log-max-age", 0, "maximum number of days to retain old, rotated log files. 0 means no limit.")
+	logMaxBackupsFlag := flag.Int("log-max-backups", 0, "maximum number of old, rotated log files to retain. 0 means no limit.")
+	logCompressFlag := flag.Bool("log-compress", false, "gzip-compress rotated log files.")
+
+	formatFlag := flag.String("format", FORMAT_RAW, "output format for captured requests. Valid options are raw, json, ndjson, cbor.")
+
+	tlsFlag := flag.Bool("tls", false, "serve HTTPS instead of plain HTTP. Requires -tls-cert and -tls-key.")
+	tlsCertFlag := flag.String("tls-cert", "", "path to the TLS server certificate (PEM).")
+	tlsKeyFlag := flag.String("tls-key", "", "path to the TLS server private key (PEM).")
+	tlsClientCAFlag := flag.String("tls-client-ca", "", "path to a CA bundle (PEM) to verify client certificates against. Enables mTLS.")
+
+	syslogFlag := flag.String("syslog", "", "remote syslog URL to ship logs and captured bodies to, e.g. udp://host:514, tcp://host:514 or tls://host:6514. Use SYSLOG as a -log/-body/-headers destination to route there.")
+	syslogFacilityFlag := flag.Int("syslog-facility", 1, "syslog facility code to report (1 = user-level messages).")
+	syslogSeverityFlag := flag.Int("syslog-severity", 6, "syslog severity code to report (6 = informational).")
+	syslogAppNameFlag := flag.String("syslog-app-name", "httpcat", "APP-NAME field to report in syslog messages.")
+
+	maxBodySizeFlag := flag.Int64("max-body-size", 10*1024*1024, "maximum number of request body bytes to capture per request. Bytes beyond this are dropped. 0 means unlimited.")
+	bodyFramingFlag := flag.String("body-framing", FRAMING_DELIMITER, "how to frame each captured body in the body destination stream. Valid options are delimiter, length-prefixed, netstring, http-record.")
+
+	upstreamFlag := flag.String("upstream", "", "URL of an upstream server to forward captured requests to and relay the response from. If unset, httpcat replies with an empty 200.")
+	captureResponseFlag := flag.Bool("capture-response", false, "also capture the upstream response body/headers, using the same destination and framing as the request. Requires -upstream.")
+
+	rulesFlag := flag.String("rules", "", "path to a YAML or JSON file of capture rules. If unset, every request is captured.")
+
 	flag.Parse()
 
+	format := *formatFlag
+	if !validFormats[format] {
+		return options{}, fmt.Errorf("format flag invalid (must be one of raw, json, ndjson, cbor): %v", format)
+	}
+
+	bodyFraming := *bodyFramingFlag
+	if !validFramings[bodyFraming] {
+		return options{}, fmt.Errorf("body-framing flag invalid (must be one of delimiter, length-prefixed, netstring, http-record): %v", bodyFraming)
+	}
+
+	if *captureResponseFlag && *upstreamFlag == "" {
+		return options{}, fmt.Errorf("-capture-response requires -upstream to be set")
+	}
+
 	netInterface := net.ParseIP(*interfaceFlag)
 	if netInterface == nil {
 		return options{}, fmt.Errorf("could not parse interface flag as IP interface=%v", *interfaceFlag)
@@ -94,38 +153,27 @@ func initFlags() (options, error) {
 		headers,
 		log,
 		logLevel,
+		format,
+		*tlsFlag,
+		*tlsCertFlag,
+		*tlsKeyFlag,
+		*tlsClientCAFlag,
+		*logMaxSizeFlag,
+		*logMaxAgeFlag,
+		*logMaxBackupsFlag,
+		*logCompressFlag,
+		*syslogFlag,
+		*syslogFacilityFlag,
+		*syslogSeverityFlag,
+		*syslogAppNameFlag,
+		*maxBodySizeFlag,
+		bodyFraming,
+		*upstreamFlag,
+		*captureResponseFlag,
+		*rulesFlag,
 	}, nil
 }
 
-func initLogging(opts options) (*slog.Logger, error) {
-	var writer io.Writer
-	if opts.log == DESTINATION_STDOUT {
-		if opts.body == DESTINATION_STDOUT || opts.headers == DESTINATION_STDOUT {
-			writer = io.Discard
-		} else {
-			writer = os.Stdout
-		}
-	} else if opts.log == DESTINATION_STDERR {
-		if opts.body == DESTINATION_STDERR || opts.headers == DESTINATION_STDERR {
-			writer = io.Discard
-		} else {
-			writer = os.Stderr
-		}
-	} else {
-		logFile, err := os.Open(opts.log)
-		if err != nil {
-			return nil, fmt.Errorf("could not open log file at %v: %w", opts.log, err)
-		}
-
-		writer = logFile
-	}
-
-	var logLevel = new(slog.LevelVar)
-	logLevel.Set(slog.Level(opts.logLevel))
-	logger := slog.New(slog.NewTextHandler(writer, &slog.HandlerOptions{Level: logLevel}))
-	return logger, nil
-}
-
 func main() {
 	log.SetOutput(os.Stderr)
 	opts, err := initFlags()
@@ -133,31 +181,68 @@ func main() {
 		log.Fatalf("Could not parse flags: %v", err)
 	}
 
-	logger, err := initLogging(opts)
+	var syslogW *syslogWriter
+	if opts.syslogURL != "" {
+		syslogW, err = newSyslogWriter(opts.syslogURL, opts.syslogFacility, opts.syslogSeverity, opts.syslogAppName)
+		if err != nil {
+			log.Fatalf("Could not configure syslog: %v", err)
+		}
+	}
+
+	logger, err := initLogging(opts, syslogW)
 	if err != nil {
 		logger.Error("Could not initialize logging", slog.Any("error", err))
 		os.Exit(1)
 	}
 
-	var bodyDest io.Writer
-	if opts.body == DESTINATION_STDOUT {
-		bodyDest = os.Stdout
-	} else if opts.body == DESTINATION_STDERR {
-		bodyDest = os.Stderr
-	} else {
-		bodyDest, err = os.Create(opts.body)
+	sinks := newSinkRegistry(syslogW)
+	bodyDest, err := sinks.get(opts.body)
+	if err != nil {
+		logger.Error("Could not open destination for writing the request bodies",
+			slog.String("destination", opts.body),
+			slog.Any("error", err),
+		)
+		os.Exit(1)
+	}
+
+	var headersDest io.Writer
+	if opts.headers != "" {
+		headersDest, err = sinks.get(opts.headers)
 		if err != nil {
-			logger.Error("Could not open file for writing the request bodies",
-				slog.String("file", opts.body),
+			logger.Error("Could not open destination for writing the request headers",
+				slog.String("destination", opts.headers),
 				slog.Any("error", err),
 			)
 			os.Exit(1)
 		}
 	}
 
+	var rules RuleSet
+	if opts.rulesPath != "" {
+		rules, err = loadRules(opts.rulesPath)
+		if err != nil {
+			logger.Error("Could not load capture rules", slog.String("file", opts.rulesPath), slog.Any("error", err))
+			os.Exit(1)
+		}
+	}
+
+	var upstreamURL *url.URL
+	if opts.upstream != "" {
+		upstreamURL, err = url.Parse(opts.upstream)
+		if err != nil {
+			logger.Error("Could not parse -upstream URL", slog.String("upstream", opts.upstream), slog.Any("error", err))
+			os.Exit(1)
+		}
+	}
+	upstreamClient := &http.Client{}
+
 	logger.Info("Initialization finished",
 		slog.String("body destination", opts.body),
 		slog.String("log destination", opts.log),
+		slog.String("format", opts.format),
+		slog.Bool("tls", opts.tlsEnabled),
+		slog.String("upstream", opts.upstream),
+		slog.String("rules", opts.rulesPath),
 	)
 
 	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -169,24 +254,123 @@ func main() {
 			slog.String("method", r.Method),
 			slog.String("path", r.URL.String()),
 		)
+		cert := clientCertInfo(r)
+		if cert != nil {
+			rlogger = rlogger.With(slog.Any("client_cert", cert))
+		}
+		if headersDest != nil {
+			if _, err := headersDest.Write(formatHeaderDump(r, cert)); err != nil {
+				rlogger.Error("Could not write request headers", slog.Any("error", err))
+			}
+		}
+
+		action, sinkName := rules.evaluate(r)
+
+		var resp *http.Response
+		var body capturedBody
+		var err error
+		if upstreamURL != nil {
+			resp, body, err = forwardToUpstream(upstreamClient, upstreamURL, r, opts.maxBodySize)
+			if err != nil {
+				rlogger.Error("Could not forward request to upstream", slog.Any("error", err))
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+		} else {
+			body, err = readCapturedBody(r.Body, opts.maxBodySize)
+			if err != nil {
+				rlogger.Error("Could not read request body for request", slog.Any("error", err))
+				return
+			}
+		}
+		rlogger = rlogger.With(
+			slog.Int64("content_length", body.ContentLength),
+			slog.String("sha256", body.SHA256),
+		)
+		if body.Truncated {
+			rlogger.Warn("Request body exceeded -max-body-size and was truncated")
+		}
+
+		dest := bodyDest
+		if sinkName != "" {
+			dest, err = sinks.get(sinkName)
+			if err != nil {
+				rlogger.Error("Could not resolve rule sink", slog.String("sink", sinkName), slog.Any("error", err))
+				dest = bodyDest
+			}
+		}
+
+		if action == RULE_ACTION_DROP {
+			rlogger.Debug("Request dropped by capture rules")
+		} else {
+			if opts.format == FORMAT_RAW {
+				if err := writeFramedBody(dest, opts.bodyFraming, opts.bodyDelimiter, body.Bytes); err != nil {
+					rlogger.Error("Could not write request body", slog.Any("error", err))
+				}
+			} else {
+				data, err := encodeEnvelope(opts.format, newRequestEnvelope(r, body))
+				if err != nil {
+					rlogger.Error("Could not encode request envelope", slog.Any("error", err))
+					return
+				}
+				if _, err := dest.Write(data); err != nil {
+					rlogger.Error("Could not write request envelope", slog.Any("error", err))
+				}
+			}
+		}
+
+		if upstreamURL == nil {
+			return
+		}
+
+		respBody, err := relayResponse(w, resp, opts.maxBodySize)
+		if err != nil {
+			rlogger.Error("Could not relay upstream response", slog.Any("error", err))
+			return
+		}
 
-		bodyReader := io.TeeReader(r.Body, bodyDest)
-		if _, err := io.ReadAll(bodyReader); err != nil {
-			rlogger.Error("Could not read request body for request", slog.Any("error", err))
+		if !opts.captureResponse || action == RULE_ACTION_DROP {
+			return
 		}
-		if _, err = bodyDest.Write([]byte(opts.bodyDelimiter)); err != nil {
-			rlogger.Error("Could not write delimiter after writing body", slog.Any("error", err))
+
+		if opts.format == FORMAT_RAW {
+			if err := writeFramedBody(dest, opts.bodyFraming, opts.bodyDelimiter, respBody.Bytes); err != nil {
+				rlogger.Error("Could not write upstream response body", slog.Any("error", err))
+			}
+			return
+		}
+
+		data, err := encodeEnvelope(opts.format, newResponseEnvelope(resp, respBody))
+		if err != nil {
+			rlogger.Error("Could not encode response envelope", slog.Any("error", err))
+			return
+		}
+		if _, err := dest.Write(data); err != nil {
+			rlogger.Error("Could not write response envelope", slog.Any("error", err))
 		}
 	})
 
+	tlsConfig, err := buildTLSConfig(opts)
+	if err != nil {
+		logger.Error("Could not configure TLS", slog.Any("error", err))
+		os.Exit(1)
+	}
+
 	server := http.Server{
-		Addr:    fmt.Sprintf("%v:%v", opts.netInterface, opts.port),
-		Handler: handler,
+		Addr:      fmt.Sprintf("%v:%v", opts.netInterface, opts.port),
+		Handler:   handler,
+		TLSConfig: tlsConfig,
 	}
 
 	go func() {
-		if err = server.ListenAndServe(); err != http.ErrServerClosed {
-			logger.Error("HTTP server crashed", slog.Any("error", err))
+		var serveErr error
+		if opts.tlsEnabled {
+			serveErr = server.ListenAndServeTLS("", "")
+		} else {
+			serveErr = server.ListenAndServe()
+		}
+		if serveErr != http.ErrServerClosed {
+			logger.Error("HTTP server crashed", slog.Any("error", serveErr))
 			os.Exit(1)
 		}
 	}()
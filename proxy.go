@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// ResponseEnvelope is the structured record emitted for an upstream
+// response when -capture-response is set, mirroring RequestEnvelope.
+type ResponseEnvelope struct {
+	StatusCode    int                 `json:"status_code" cbor:"status_code"`
+	Headers       map[string][]string `json:"headers" cbor:"headers"`
+	Timestamp     time.Time           `json:"timestamp" cbor:"timestamp"`
+	BodyBase64    string              `json:"body_base64" cbor:"body_base64"`
+	ContentLength int64               `json:"content_length" cbor:"content_length"`
+	SHA256        string              `json:"sha256" cbor:"sha256"`
+	Truncated     bool                `json:"truncated,omitempty" cbor:"truncated,omitempty"`
+}
+
+func newResponseEnvelope(resp *http.Response, body capturedBody) ResponseEnvelope {
+	return ResponseEnvelope{
+		StatusCode:    resp.StatusCode,
+		Headers:       map[string][]string(resp.Header),
+		Timestamp:     time.Now(),
+		BodyBase64:    base64.StdEncoding.EncodeToString(body.Bytes),
+		ContentLength: body.ContentLength,
+		SHA256:        body.SHA256,
+		Truncated:     body.Truncated,
+	}
+}
+
+// forwardToUpstream replays r against upstream, streaming the original
+// request body straight through so upstream always sees the full body
+// regardless of -max-body-size, while teeing it into a bounded buffer so
+// the caller can still capture (and log) up to that many bytes. r.Body is
+// fully drained by the round-trip, so this performs a manual round-trip
+// rather than using httputil.ReverseProxy.
+func forwardToUpstream(client *http.Client, upstream *url.URL, r *http.Request, maxBodySize int64) (*http.Response, capturedBody, error) {
+	target := *upstream
+	target.Path = upstream.Path + r.URL.Path
+	target.RawQuery = r.URL.RawQuery
+
+	bb := &boundedBuffer{limit: maxBodySize}
+	tee := io.TeeReader(r.Body, bb)
+
+	outReq, err := http.NewRequestWithContext(r.Context(), r.Method, target.String(), tee)
+	if err != nil {
+		return nil, capturedBody{}, fmt.Errorf("could not build upstream request: %w", err)
+	}
+	outReq.Header = r.Header.Clone()
+	outReq.ContentLength = r.ContentLength
+
+	resp, err := client.Do(outReq)
+	if err != nil {
+		return nil, capturedBody{}, fmt.Errorf("could not reach upstream %v: %w", upstream, err)
+	}
+	return resp, finalizeCapturedBody(bb), nil
+}
+
+// relayResponse streams resp straight through to w so the client always
+// gets the full, byte-for-byte response regardless of -max-body-size,
+// while teeing it into a bounded buffer so the caller can still capture
+// (and log) up to that many bytes.
+func relayResponse(w http.ResponseWriter, resp *http.Response, maxBodySize int64) (capturedBody, error) {
+	defer resp.Body.Close()
+
+	for name, values := range resp.Header {
+		for _, value := range values {
+			w.Header().Add(name, value)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+
+	bb := &boundedBuffer{limit: maxBodySize}
+	tee := io.TeeReader(resp.Body, bb)
+	if _, err := io.Copy(w, tee); err != nil {
+		return finalizeCapturedBody(bb), err
+	}
+	return finalizeCapturedBody(bb), nil
+}
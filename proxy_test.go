@@ -0,0 +1,75 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestForwardToUpstreamAndRelayResponseRoundTrip(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Fatalf("upstream could not read request body: %v", err)
+		}
+		if string(body) != "request body beyond the capture limit" {
+			t.Fatalf("upstream received %q, want full original body", body)
+		}
+		w.Header().Set("X-Upstream", "yes")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("response body beyond the capture limit"))
+	}))
+	defer upstream.Close()
+
+	upstreamURL, err := url.Parse(upstream.URL)
+	if err != nil {
+		t.Fatalf("could not parse upstream URL: %v", err)
+	}
+
+	reqBody := "request body beyond the capture limit"
+	r := httptest.NewRequest(http.MethodPost, "/widgets", strings.NewReader(reqBody))
+	r.ContentLength = int64(len(reqBody))
+
+	resp, capturedReq, err := forwardToUpstream(upstream.Client(), upstreamURL, r, 10)
+	if err != nil {
+		t.Fatalf("forwardToUpstream returned error: %v", err)
+	}
+	if !capturedReq.Truncated {
+		t.Fatal("expected the captured request body to be truncated")
+	}
+	if string(capturedReq.Bytes) != reqBody[:10] {
+		t.Fatalf("captured request bytes = %q, want %q", capturedReq.Bytes, reqBody[:10])
+	}
+	if capturedReq.ContentLength != int64(len(reqBody)) {
+		t.Fatalf("captured request ContentLength = %d, want true size %d", capturedReq.ContentLength, len(reqBody))
+	}
+
+	w := httptest.NewRecorder()
+	capturedResp, err := relayResponse(w, resp, 10)
+	if err != nil {
+		t.Fatalf("relayResponse returned error: %v", err)
+	}
+
+	wantRespBody := "response body beyond the capture limit"
+	if w.Body.String() != wantRespBody {
+		t.Fatalf("client received %q, want full upstream response %q", w.Body.String(), wantRespBody)
+	}
+	if w.Code != http.StatusCreated {
+		t.Fatalf("client status = %d, want %d", w.Code, http.StatusCreated)
+	}
+	if w.Header().Get("X-Upstream") != "yes" {
+		t.Fatal("expected upstream response headers to be relayed to the client")
+	}
+	if !capturedResp.Truncated {
+		t.Fatal("expected the captured response body to be truncated")
+	}
+	if string(capturedResp.Bytes) != wantRespBody[:10] {
+		t.Fatalf("captured response bytes = %q, want %q", capturedResp.Bytes, wantRespBody[:10])
+	}
+	if capturedResp.ContentLength != int64(len(wantRespBody)) {
+		t.Fatalf("captured response ContentLength = %d, want true size %d", capturedResp.ContentLength, len(wantRespBody))
+	}
+}
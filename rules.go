@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	RULE_ACTION_CAPTURE = "capture"
+	RULE_ACTION_DROP    = "drop"
+)
+
+// Rule is a single capture predicate plus the action to take when it
+// matches. Rules are evaluated in file order and the first match wins; if
+// no rule matches, the request is captured to the default sink.
+type Rule struct {
+	Name        string            `yaml:"name" json:"name"`
+	Method      string            `yaml:"method,omitempty" json:"method,omitempty"`
+	PathGlob    string            `yaml:"path_glob,omitempty" json:"path_glob,omitempty"`
+	PathRegex   string            `yaml:"path_regex,omitempty" json:"path_regex,omitempty"`
+	Header      map[string]string `yaml:"header,omitempty" json:"header,omitempty"`
+	ContentType string            `yaml:"content_type,omitempty" json:"content_type,omitempty"`
+	MinBodySize int64             `yaml:"min_body_size,omitempty" json:"min_body_size,omitempty"`
+	MaxBodySize int64             `yaml:"max_body_size,omitempty" json:"max_body_size,omitempty"`
+	Action      string            `yaml:"action" json:"action"`
+	SampleRate  float64           `yaml:"sample_rate,omitempty" json:"sample_rate,omitempty"`
+	Sink        string            `yaml:"sink,omitempty" json:"sink,omitempty"`
+
+	pathRegex *regexp.Regexp
+}
+
+// RuleSet is an ordered list of rules loaded from -rules.
+type RuleSet []Rule
+
+// loadRules reads and compiles a rule file. YAML and JSON are both
+// accepted, since JSON is valid YAML.
+func loadRules(path string) (RuleSet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read rules file at %v: %w", path, err)
+	}
+
+	var rules RuleSet
+	if err := yaml.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("could not parse rules file at %v: %w", path, err)
+	}
+
+	for i := range rules {
+		if rules[i].Action != RULE_ACTION_CAPTURE && rules[i].Action != RULE_ACTION_DROP {
+			return nil, fmt.Errorf("rule %v: invalid action %q (must be capture or drop)", rules[i].Name, rules[i].Action)
+		}
+		if rules[i].PathRegex != "" {
+			re, err := regexp.Compile(rules[i].PathRegex)
+			if err != nil {
+				return nil, fmt.Errorf("rule %v: invalid path_regex: %w", rules[i].Name, err)
+			}
+			rules[i].pathRegex = re
+		}
+	}
+
+	return rules, nil
+}
+
+func (r Rule) matches(req *http.Request) bool {
+	if r.Method != "" && !strings.EqualFold(r.Method, req.Method) {
+		return false
+	}
+
+	if r.PathGlob != "" {
+		ok, err := path.Match(r.PathGlob, req.URL.Path)
+		if err != nil || !ok {
+			return false
+		}
+	}
+
+	if r.pathRegex != nil && !r.pathRegex.MatchString(req.URL.Path) {
+		return false
+	}
+
+	for name, value := range r.Header {
+		if !strings.EqualFold(req.Header.Get(name), value) {
+			return false
+		}
+	}
+
+	if r.ContentType != "" && !strings.HasPrefix(req.Header.Get("Content-Type"), r.ContentType) {
+		return false
+	}
+
+	if r.MinBodySize > 0 && req.ContentLength < r.MinBodySize {
+		return false
+	}
+	if r.MaxBodySize > 0 && req.ContentLength > r.MaxBodySize {
+		return false
+	}
+
+	return true
+}
+
+// evaluate returns the action and sink for req: the first matching rule's
+// action/sink, or a default "capture" to the default sink if none match.
+// A "capture" rule with a sample rate below 1 is randomly downgraded to
+// "drop" for the fraction of requests outside the sample.
+func (rules RuleSet) evaluate(req *http.Request) (action string, sink string) {
+	for _, rule := range rules {
+		if !rule.matches(req) {
+			continue
+		}
+
+		if rule.Action == RULE_ACTION_CAPTURE && rule.SampleRate > 0 && rule.SampleRate < 1 {
+			if rand.Float64() >= rule.SampleRate {
+				return RULE_ACTION_DROP, ""
+			}
+		}
+
+		return rule.Action, rule.Sink
+	}
+
+	return RULE_ACTION_CAPTURE, ""
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"math/rand"
+	"net/http"
+	"net/url"
+	"regexp"
+	"testing"
+)
+
+func newTestRequest(method, path string, headers map[string]string, contentLength int64) *http.Request {
+	r := &http.Request{
+		Method:        method,
+		URL:           &url.URL{Path: path},
+		Header:        make(http.Header),
+		ContentLength: contentLength,
+	}
+	for name, value := range headers {
+		r.Header.Set(name, value)
+	}
+	return r
+}
+
+func TestRuleMatchesMethod(t *testing.T) {
+	r := Rule{Method: "POST"}
+	if !r.matches(newTestRequest("POST", "/", nil, 0)) {
+		t.Fatal("expected POST request to match a POST rule")
+	}
+	if r.matches(newTestRequest("GET", "/", nil, 0)) {
+		t.Fatal("expected GET request not to match a POST rule")
+	}
+}
+
+func TestRuleMatchesPathGlob(t *testing.T) {
+	r := Rule{PathGlob: "/api/*"}
+	if !r.matches(newTestRequest("GET", "/api/widgets", nil, 0)) {
+		t.Fatal("expected /api/widgets to match /api/*")
+	}
+	if r.matches(newTestRequest("GET", "/other", nil, 0)) {
+		t.Fatal("expected /other not to match /api/*")
+	}
+}
+
+func TestRuleMatchesPathRegex(t *testing.T) {
+	re := regexp.MustCompile(`^/users/\d+$`)
+	r := Rule{PathRegex: `^/users/\d+$`, pathRegex: re}
+	if !r.matches(newTestRequest("GET", "/users/42", nil, 0)) {
+		t.Fatal("expected /users/42 to match the regex")
+	}
+	if r.matches(newTestRequest("GET", "/users/abc", nil, 0)) {
+		t.Fatal("expected /users/abc not to match the regex")
+	}
+}
+
+func TestRuleMatchesHeader(t *testing.T) {
+	r := Rule{Header: map[string]string{"X-Env": "prod"}}
+	if !r.matches(newTestRequest("GET", "/", map[string]string{"X-Env": "prod"}, 0)) {
+		t.Fatal("expected matching header to match")
+	}
+	if r.matches(newTestRequest("GET", "/", map[string]string{"X-Env": "staging"}, 0)) {
+		t.Fatal("expected mismatched header not to match")
+	}
+}
+
+func TestRuleMatchesContentType(t *testing.T) {
+	r := Rule{ContentType: "application/json"}
+	if !r.matches(newTestRequest("GET", "/", map[string]string{"Content-Type": "application/json; charset=utf-8"}, 0)) {
+		t.Fatal("expected content type prefix match")
+	}
+	if r.matches(newTestRequest("GET", "/", map[string]string{"Content-Type": "text/plain"}, 0)) {
+		t.Fatal("expected non-matching content type not to match")
+	}
+}
+
+func TestRuleMatchesBodySizeBounds(t *testing.T) {
+	r := Rule{MinBodySize: 10, MaxBodySize: 100}
+	if r.matches(newTestRequest("GET", "/", nil, 5)) {
+		t.Fatal("expected body below min not to match")
+	}
+	if !r.matches(newTestRequest("GET", "/", nil, 50)) {
+		t.Fatal("expected body within bounds to match")
+	}
+	if r.matches(newTestRequest("GET", "/", nil, 500)) {
+		t.Fatal("expected body above max not to match")
+	}
+}
+
+func TestRuleMatchesCombinedPredicates(t *testing.T) {
+	r := Rule{Method: "POST", PathGlob: "/api/*", ContentType: "application/json"}
+	req := newTestRequest("POST", "/api/widgets", map[string]string{"Content-Type": "application/json"}, 0)
+	if !r.matches(req) {
+		t.Fatal("expected request satisfying all predicates to match")
+	}
+
+	req.Method = "GET"
+	if r.matches(req) {
+		t.Fatal("expected request failing one predicate not to match")
+	}
+}
+
+func TestRuleSetEvaluateFirstMatchWins(t *testing.T) {
+	rules := RuleSet{
+		{Name: "drop-health", PathGlob: "/health", Action: RULE_ACTION_DROP},
+		{Name: "capture-all", PathGlob: "/*", Action: RULE_ACTION_CAPTURE, Sink: "default.log"},
+	}
+
+	action, sink := rules.evaluate(newTestRequest("GET", "/health", nil, 0))
+	if action != RULE_ACTION_DROP || sink != "" {
+		t.Fatalf("evaluate = (%q, %q), want (drop, \"\")", action, sink)
+	}
+}
+
+func TestRuleSetEvaluateDefaultsToCapture(t *testing.T) {
+	rules := RuleSet{
+		{Name: "drop-health", PathGlob: "/health", Action: RULE_ACTION_DROP},
+	}
+
+	action, sink := rules.evaluate(newTestRequest("GET", "/other", nil, 0))
+	if action != RULE_ACTION_CAPTURE || sink != "" {
+		t.Fatalf("evaluate = (%q, %q), want (capture, \"\")", action, sink)
+	}
+}
+
+func TestRuleSetEvaluateNamedSink(t *testing.T) {
+	rules := RuleSet{
+		{Name: "api", PathGlob: "/api/*", Action: RULE_ACTION_CAPTURE, Sink: "api.log"},
+	}
+
+	action, sink := rules.evaluate(newTestRequest("GET", "/api/widgets", nil, 0))
+	if action != RULE_ACTION_CAPTURE || sink != "api.log" {
+		t.Fatalf("evaluate = (%q, %q), want (capture, api.log)", action, sink)
+	}
+}
+
+func TestRuleSetEvaluateSampleRateBoundaries(t *testing.T) {
+	// SampleRate <= 0 or >= 1 must bypass math/rand entirely and always
+	// capture, regardless of the global rand source's state.
+	rand.Seed(1)
+
+	zeroRate := RuleSet{{Name: "zero", Action: RULE_ACTION_CAPTURE, SampleRate: 0}}
+	for i := 0; i < 20; i++ {
+		if action, _ := zeroRate.evaluate(newTestRequest("GET", "/", nil, 0)); action != RULE_ACTION_CAPTURE {
+			t.Fatalf("SampleRate=0 should always capture, got %q", action)
+		}
+	}
+
+	fullRate := RuleSet{{Name: "full", Action: RULE_ACTION_CAPTURE, SampleRate: 1}}
+	for i := 0; i < 20; i++ {
+		if action, _ := fullRate.evaluate(newTestRequest("GET", "/", nil, 0)); action != RULE_ACTION_CAPTURE {
+			t.Fatalf("SampleRate=1 should always capture, got %q", action)
+		}
+	}
+}
+
+func TestRuleSetEvaluateSampleRatePartial(t *testing.T) {
+	rules := RuleSet{{Name: "half", Action: RULE_ACTION_CAPTURE, SampleRate: 0.5}}
+
+	var captured, dropped int
+	for i := 0; i < 200; i++ {
+		action, _ := rules.evaluate(newTestRequest("GET", "/", nil, 0))
+		switch action {
+		case RULE_ACTION_CAPTURE:
+			captured++
+		case RULE_ACTION_DROP:
+			dropped++
+		default:
+			t.Fatalf("unexpected action %q", action)
+		}
+	}
+
+	if captured == 0 || dropped == 0 {
+		t.Fatalf("expected a mix of capture and drop at SampleRate=0.5, got captured=%d dropped=%d", captured, dropped)
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	syslogMinBackoff = time.Second
+	syslogMaxBackoff = 30 * time.Second
+)
+
+// syslogWriter is an io.Writer that ships each write as an RFC 5424 syslog
+// message over UDP, TCP or TLS. Connection failures are retried with
+// bounded exponential backoff rather than surfaced on every write, so a
+// transient network blip doesn't take down request capture.
+type syslogWriter struct {
+	network  string
+	addr     string
+	tlsConf  *tls.Config
+	facility int
+	severity int
+	appName  string
+	hostname string
+
+	mu           sync.Mutex
+	conn         net.Conn
+	backoff      time.Duration
+	nextDialTime time.Time
+}
+
+// newSyslogWriter parses a destination URL such as udp://host:514,
+// tcp://host:514 or tls://host:6514 and returns a writer that connects
+// lazily on the first write.
+func newSyslogWriter(rawURL string, facility, severity int, appName string) (*syslogWriter, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse syslog URL %v: %w", rawURL, err)
+	}
+
+	var network string
+	var tlsConf *tls.Config
+	switch u.Scheme {
+	case "udp":
+		network = "udp"
+	case "tcp":
+		network = "tcp"
+	case "tls":
+		network = "tcp"
+		tlsConf = &tls.Config{ServerName: u.Hostname()}
+	default:
+		return nil, fmt.Errorf("unsupported syslog scheme %v (expected udp, tcp or tls)", u.Scheme)
+	}
+
+	if u.Host == "" {
+		return nil, fmt.Errorf("syslog URL %v is missing a host", rawURL)
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "-"
+	}
+
+	return &syslogWriter{
+		network:  network,
+		addr:     u.Host,
+		tlsConf:  tlsConf,
+		facility: facility,
+		severity: severity,
+		appName:  appName,
+		hostname: hostname,
+	}, nil
+}
+
+func (w *syslogWriter) dial() (net.Conn, error) {
+	if w.tlsConf != nil {
+		return tls.Dial(w.network, w.addr, w.tlsConf)
+	}
+	return net.Dial(w.network, w.addr)
+}
+
+func (w *syslogWriter) connect() (net.Conn, error) {
+	if w.conn != nil {
+		return w.conn, nil
+	}
+
+	if time.Now().Before(w.nextDialTime) {
+		return nil, fmt.Errorf("syslog backoff active, not reconnecting to %v yet", w.addr)
+	}
+
+	conn, err := w.dial()
+	if err != nil {
+		if w.backoff == 0 {
+			w.backoff = syslogMinBackoff
+		} else if w.backoff < syslogMaxBackoff {
+			w.backoff *= 2
+		}
+		w.nextDialTime = time.Now().Add(w.backoff)
+		return nil, fmt.Errorf("could not connect to syslog server at %v: %w", w.addr, err)
+	}
+
+	w.backoff = 0
+	w.conn = conn
+	return conn, nil
+}
+
+// formatSyslogMessage renders p as a single RFC 5424 syslog message, ready
+// to be written to the wire. It's pulled out of Write so the framing can be
+// tested without a live connection.
+func formatSyslogMessage(facility, severity int, hostname, appName string, now time.Time, p []byte) string {
+	priority := facility*8 + severity
+	return fmt.Sprintf("<%d>1 %s %s %s - - - %s\n",
+		priority,
+		now.UTC().Format(time.RFC3339),
+		hostname,
+		appName,
+		p,
+	)
+}
+
+// Write formats p as a single RFC 5424 syslog message and sends it,
+// reconnecting first if necessary.
+func (w *syslogWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	conn, err := w.connect()
+	if err != nil {
+		return 0, err
+	}
+
+	msg := formatSyslogMessage(w.facility, w.severity, w.hostname, w.appName, time.Now(), p)
+
+	if _, err := conn.Write([]byte(msg)); err != nil {
+		conn.Close()
+		w.conn = nil
+		return 0, fmt.Errorf("could not write to syslog server at %v: %w", w.addr, err)
+	}
+
+	return len(p), nil
+}
+
+// bestEffortWriter swallows write errors from the wrapped writer instead of
+// propagating them, logging them instead. It exists so a flaky sink (like a
+// syslog connection mid-backoff) can sit behind an io.MultiWriter without
+// its errors aborting writes to the other destinations in the fan-out.
+type bestEffortWriter struct {
+	io.Writer
+}
+
+func (w bestEffortWriter) Write(p []byte) (int, error) {
+	if _, err := w.Writer.Write(p); err != nil {
+		log.Printf("httpcat: dropping write to unreachable sink: %v", err)
+	}
+	return len(p), nil
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatSyslogMessagePriority(t *testing.T) {
+	now := time.Date(2026, 7, 25, 12, 0, 0, 0, time.UTC)
+	msg := formatSyslogMessage(16, 6, "host1", "httpcat", now, []byte("hello"))
+
+	if !strings.HasPrefix(msg, "<134>1 ") {
+		t.Fatalf("expected priority 134 (16*8+6), got message: %q", msg)
+	}
+}
+
+func TestFormatSyslogMessageFields(t *testing.T) {
+	now := time.Date(2026, 7, 25, 12, 0, 0, 0, time.UTC)
+	msg := formatSyslogMessage(1, 5, "host1", "httpcat", now, []byte("hello"))
+
+	want := "<13>1 2026-07-25T12:00:00Z host1 httpcat - - - hello\n"
+	if msg != want {
+		t.Fatalf("formatSyslogMessage = %q, want %q", msg, want)
+	}
+}
+
+func TestFormatSyslogMessageNonUTCTimeIsNormalized(t *testing.T) {
+	loc := time.FixedZone("TEST", 2*60*60)
+	now := time.Date(2026, 7, 25, 14, 0, 0, 0, loc)
+	msg := formatSyslogMessage(1, 5, "host1", "httpcat", now, []byte("hello"))
+
+	if !strings.Contains(msg, "2026-07-25T12:00:00Z") {
+		t.Fatalf("expected timestamp normalized to UTC, got: %q", msg)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// ClientCertInfo summarizes the certificate a client presented during mTLS,
+// for inclusion in logs and envelopes alongside the request it authenticated.
+type ClientCertInfo struct {
+	Subject string   `json:"subject" cbor:"subject"`
+	SANs    []string `json:"sans,omitempty" cbor:"sans,omitempty"`
+}
+
+func clientCertInfo(r *http.Request) *ClientCertInfo {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return nil
+	}
+
+	cert := r.TLS.PeerCertificates[0]
+	sans := append([]string{}, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+
+	return &ClientCertInfo{
+		Subject: cert.Subject.String(),
+		SANs:    sans,
+	}
+}
+
+// buildTLSConfig loads the server certificate/key and, if a client CA bundle
+// is configured, enables and verifies mutual TLS. It returns nil if TLS was
+// not requested.
+func buildTLSConfig(opts options) (*tls.Config, error) {
+	if !opts.tlsEnabled {
+		return nil, nil
+	}
+
+	if opts.tlsCert == "" || opts.tlsKey == "" {
+		return nil, fmt.Errorf("-tls requires both -tls-cert and -tls-key to be set")
+	}
+
+	cert, err := tls.LoadX509KeyPair(opts.tlsCert, opts.tlsKey)
+	if err != nil {
+		return nil, fmt.Errorf("could not load TLS certificate/key pair: %w", err)
+	}
+
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+	}
+
+	if opts.tlsClientCA != "" {
+		caBytes, err := os.ReadFile(opts.tlsClientCA)
+		if err != nil {
+			return nil, fmt.Errorf("could not read TLS client CA file at %v: %w", opts.tlsClientCA, err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caBytes) {
+			return nil, fmt.Errorf("could not parse any certificates from TLS client CA file at %v", opts.tlsClientCA)
+		}
+
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"net/http"
+	"testing"
+)
+
+func TestClientCertInfoNoTLS(t *testing.T) {
+	r := &http.Request{}
+	if info := clientCertInfo(r); info != nil {
+		t.Fatalf("expected nil for a non-TLS request, got %+v", info)
+	}
+}
+
+func TestClientCertInfoNoPeerCertificates(t *testing.T) {
+	r := &http.Request{TLS: &tls.ConnectionState{}}
+	if info := clientCertInfo(r); info != nil {
+		t.Fatalf("expected nil when no peer certificates were presented, got %+v", info)
+	}
+}
+
+func TestClientCertInfoSubjectAndSANs(t *testing.T) {
+	cert := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "client.example.com"},
+		DNSNames:    []string{"alt.example.com"},
+		IPAddresses: []net.IP{net.ParseIP("10.0.0.1")},
+	}
+	r := &http.Request{TLS: &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}}
+
+	info := clientCertInfo(r)
+	if info == nil {
+		t.Fatal("expected non-nil ClientCertInfo")
+	}
+	if info.Subject != cert.Subject.String() {
+		t.Fatalf("Subject = %q, want %q", info.Subject, cert.Subject.String())
+	}
+
+	wantSANs := []string{"alt.example.com", "10.0.0.1"}
+	if len(info.SANs) != len(wantSANs) {
+		t.Fatalf("SANs = %v, want %v", info.SANs, wantSANs)
+	}
+	for i, want := range wantSANs {
+		if info.SANs[i] != want {
+			t.Fatalf("SANs[%d] = %q, want %q", i, info.SANs[i], want)
+		}
+	}
+}